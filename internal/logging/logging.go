@@ -0,0 +1,84 @@
+// Package logging adapts a configured *logrus.Logger into a *slog.Logger so
+// that internal packages can depend on the standard library's slog
+// interface instead of logrus directly, while the CLI keeps its existing
+// nested-logrus-formatter output.
+package logging
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/sirupsen/logrus"
+)
+
+// logrusHandler implements slog.Handler by forwarding records to a logrus.Logger.
+type logrusHandler struct {
+	logger *logrus.Logger
+	attrs  []slog.Attr
+	groups []string
+}
+
+// NewLogrusHandler returns a slog.Handler backed by logger, preserving
+// whatever formatter and level the caller configured on it.
+func NewLogrusHandler(logger *logrus.Logger) slog.Handler {
+	return &logrusHandler{logger: logger}
+}
+
+// NewLogrusLogger is a convenience wrapper returning a ready-to-use slog.Logger.
+func NewLogrusLogger(logger *logrus.Logger) *slog.Logger {
+	return slog.New(NewLogrusHandler(logger))
+}
+
+func (h *logrusHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.logger.IsLevelEnabled(toLogrusLevel(level))
+}
+
+func (h *logrusHandler) Handle(_ context.Context, record slog.Record) error {
+	fields := make(logrus.Fields, len(h.attrs)+record.NumAttrs())
+	for _, a := range h.attrs {
+		fields[h.qualify(a.Key)] = a.Value.Any()
+	}
+	record.Attrs(func(a slog.Attr) bool {
+		fields[h.qualify(a.Key)] = a.Value.Any()
+		return true
+	})
+
+	h.logger.WithFields(fields).Log(toLogrusLevel(record.Level), record.Message)
+	return nil
+}
+
+func (h *logrusHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	next := &logrusHandler{logger: h.logger, groups: h.groups}
+	next.attrs = append(append([]slog.Attr(nil), h.attrs...), attrs...)
+	return next
+}
+
+func (h *logrusHandler) WithGroup(name string) slog.Handler {
+	next := &logrusHandler{logger: h.logger, attrs: h.attrs}
+	next.groups = append(append([]string(nil), h.groups...), name)
+	return next
+}
+
+func (h *logrusHandler) qualify(key string) string {
+	if len(h.groups) == 0 {
+		return key
+	}
+	qualified := key
+	for i := len(h.groups) - 1; i >= 0; i-- {
+		qualified = h.groups[i] + "." + qualified
+	}
+	return qualified
+}
+
+func toLogrusLevel(level slog.Level) logrus.Level {
+	switch {
+	case level >= slog.LevelError:
+		return logrus.ErrorLevel
+	case level >= slog.LevelWarn:
+		return logrus.WarnLevel
+	case level >= slog.LevelInfo:
+		return logrus.InfoLevel
+	default:
+		return logrus.DebugLevel
+	}
+}
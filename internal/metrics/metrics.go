@@ -0,0 +1,180 @@
+// Package metrics exposes Prometheus instrumentation for the proxy so that
+// internal packages can report operational health without taking a
+// dependency on any particular logging or CLI framework.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics bundles the Prometheus collectors emitted by the proxy. A nil
+// *Metrics is valid and every method is a no-op against it, so callers that
+// do not configure a listen address can pass nil through unconditionally.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	ConnectionsAccepted      prometheus.Counter
+	UpstreamDialFailures     prometheus.Counter
+	ClientHelloParseFailures prometheus.Counter
+	RecordsSplit             prometheus.Counter
+	RecordsForwarded         prometheus.Counter
+	GapDuration              prometheus.Histogram
+	BytesRelayed             *prometheus.CounterVec
+}
+
+// New creates a Metrics instance registered against a fresh Prometheus
+// registry so multiple Server instances in the same process do not collide
+// on the default global registry.
+func New() *Metrics {
+	reg := prometheus.NewRegistry()
+	factory := promauto.With(reg)
+
+	return &Metrics{
+		registry: reg,
+		ConnectionsAccepted: factory.NewCounter(prometheus.CounterOpts{
+			Namespace: "terasu",
+			Name:      "connections_accepted_total",
+			Help:      "Total number of client connections accepted by the proxy.",
+		}),
+		UpstreamDialFailures: factory.NewCounter(prometheus.CounterOpts{
+			Namespace: "terasu",
+			Name:      "upstream_dial_failures_total",
+			Help:      "Total number of failed dials to the upstream destination.",
+		}),
+		ClientHelloParseFailures: factory.NewCounter(prometheus.CounterOpts{
+			Namespace: "terasu",
+			Name:      "clienthello_parse_failures_total",
+			Help:      "Total number of initial records that could not be parsed as a ClientHello.",
+		}),
+		RecordsSplit: factory.NewCounter(prometheus.CounterOpts{
+			Namespace: "terasu",
+			Name:      "records_split_total",
+			Help:      "Total number of ClientHello records split into multiple TLS records.",
+		}),
+		RecordsForwarded: factory.NewCounter(prometheus.CounterOpts{
+			Namespace: "terasu",
+			Name:      "records_forwarded_total",
+			Help:      "Total number of ClientHello records forwarded without splitting.",
+		}),
+		GapDuration: factory.NewHistogram(prometheus.HistogramOpts{
+			Namespace: "terasu",
+			Name:      "gap_duration_seconds",
+			Help:      "Applied gap duration between split TLS record fragments.",
+			Buckets:   prometheus.ExponentialBuckets(0.0001, 2, 16),
+		}),
+		BytesRelayed: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "terasu",
+			Name:      "bytes_relayed_total",
+			Help:      "Total bytes relayed between client and upstream, labelled by direction.",
+		}, []string{"direction"}),
+	}
+}
+
+// IncConnectionsAccepted records an accepted client connection.
+func (m *Metrics) IncConnectionsAccepted() {
+	if m == nil {
+		return
+	}
+	m.ConnectionsAccepted.Inc()
+}
+
+// IncUpstreamDialFailures records a failed upstream dial.
+func (m *Metrics) IncUpstreamDialFailures() {
+	if m == nil {
+		return
+	}
+	m.UpstreamDialFailures.Inc()
+}
+
+// IncClientHelloParseFailures records an initial record that failed ClientHello parsing.
+func (m *Metrics) IncClientHelloParseFailures() {
+	if m == nil {
+		return
+	}
+	m.ClientHelloParseFailures.Inc()
+}
+
+// IncRecordsSplit records a ClientHello that was split into multiple records.
+func (m *Metrics) IncRecordsSplit() {
+	if m == nil {
+		return
+	}
+	m.RecordsSplit.Inc()
+}
+
+// IncRecordsForwarded records a ClientHello that was forwarded without splitting.
+func (m *Metrics) IncRecordsForwarded() {
+	if m == nil {
+		return
+	}
+	m.RecordsForwarded.Inc()
+}
+
+// ObserveGap records the gap duration applied between two split fragments.
+func (m *Metrics) ObserveGap(d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.GapDuration.Observe(d.Seconds())
+}
+
+// AddBytesRelayed records bytes moved in the given direction ("client_to_upstream" or "upstream_to_client").
+func (m *Metrics) AddBytesRelayed(direction string, n int64) {
+	if m == nil || n <= 0 {
+		return
+	}
+	m.BytesRelayed.WithLabelValues(direction).Add(float64(n))
+}
+
+// Handler returns the HTTP handler serving this Metrics instance's registry.
+func (m *Metrics) Handler() http.Handler {
+	if m == nil {
+		return http.NotFoundHandler()
+	}
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Serve starts an HTTP server exposing /metrics at addr and blocks until ctx
+// is cancelled or the server fails to serve. It is a no-op if m is nil or
+// addr is empty.
+func (m *Metrics) Serve(ctx context.Context, addr string) error {
+	if m == nil || addr == "" {
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m.Handler())
+
+	srv := &http.Server{Addr: addr, Handler: mux}
+
+	errCh := make(chan error, 1)
+	go func() {
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			errCh <- fmt.Errorf("listen %s: %w", addr, err)
+			return
+		}
+		errCh <- srv.Serve(ln)
+	}()
+
+	select {
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return srv.Shutdown(shutdownCtx)
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	}
+}
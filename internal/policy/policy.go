@@ -0,0 +1,232 @@
+// Package policy implements the per-destination override engine that lets
+// an operator tune split parameters for specific SNIs, destination
+// networks, or ports instead of applying one schedule to every connection.
+package policy
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/Nativu5/terasu-proxy/internal/tls"
+)
+
+// Rule overrides split parameters for connections matching its criteria.
+// Unset (nil/zero) fields fall through to the engine's default and,
+// beneath that, the process-wide flags.
+type Rule struct {
+	Name string `yaml:"name"`
+
+	SNI  string `yaml:"sni"`
+	CIDR string `yaml:"cidr"`
+	Port int    `yaml:"port"`
+
+	FirstFragment *int    `yaml:"first"`
+	SplitOffsets  []int   `yaml:"splits"`
+	SplitRandom   int     `yaml:"splits_random"`
+	GapMinMs      *int    `yaml:"gap_min_ms"`
+	GapMaxMs      *int    `yaml:"gap_max_ms"`
+	GapDist       string  `yaml:"gap_dist"`
+	ProxyProtocol *string `yaml:"proxy_protocol"`
+
+	sniRe *regexp.Regexp
+	cidr  *net.IPNet
+}
+
+// Document is the on-disk shape of a policy file: a list of match rules
+// evaluated in order, plus an optional default applied when none match.
+type Document struct {
+	Rules   []Rule `yaml:"rules"`
+	Default Rule   `yaml:"default"`
+}
+
+// Override is the resolved set of split parameters for one connection.
+type Override struct {
+	Splits      tls.Splits
+	HasSplits   bool
+	GapMin      time.Duration
+	GapMax      time.Duration
+	HasGap      bool
+	GapDist     tls.GapDistribution
+	HasGapDist  bool
+
+	ProxyProtocol    string
+	HasProxyProtocol bool
+
+	MatchedRule string
+}
+
+// Engine evaluates rules against a connection's SNI/destination and
+// supports SIGHUP-triggered hot reload of the backing file.
+type Engine struct {
+	path string
+
+	mu       sync.RWMutex
+	rules    []Rule
+	fallback Rule
+}
+
+// Load reads and compiles the policy document at path.
+func Load(path string) (*Engine, error) {
+	e := &Engine{path: path}
+	if err := e.Reload(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Reload re-reads and recompiles the policy document from disk, atomically
+// swapping it in so concurrent Resolve calls never see a half-updated set.
+func (e *Engine) Reload() error {
+	raw, err := os.ReadFile(e.path)
+	if err != nil {
+		return fmt.Errorf("read policy file %s: %w", e.path, err)
+	}
+
+	var doc Document
+	if err := yaml.Unmarshal(raw, &doc); err != nil {
+		return fmt.Errorf("parse policy file %s: %w", e.path, err)
+	}
+
+	rules := make([]Rule, len(doc.Rules))
+	for i, r := range doc.Rules {
+		if err := r.compile(); err != nil {
+			return fmt.Errorf("policy rule %d (%s): %w", i, r.Name, err)
+		}
+		rules[i] = r
+	}
+	if err := doc.Default.compile(); err != nil {
+		return fmt.Errorf("policy default rule: %w", err)
+	}
+
+	e.mu.Lock()
+	e.rules = rules
+	e.fallback = doc.Default
+	e.mu.Unlock()
+	return nil
+}
+
+func (r *Rule) compile() error {
+	if r.SNI != "" {
+		re, err := regexp.Compile(r.SNI)
+		if err != nil {
+			return fmt.Errorf("compile sni pattern %q: %w", r.SNI, err)
+		}
+		r.sniRe = re
+	}
+	if r.CIDR != "" {
+		_, ipNet, err := net.ParseCIDR(r.CIDR)
+		if err != nil {
+			return fmt.Errorf("parse cidr %q: %w", r.CIDR, err)
+		}
+		r.cidr = ipNet
+	}
+	return nil
+}
+
+func (r *Rule) matches(sni string, destIP net.IP, port int) bool {
+	if r.SNI != "" {
+		if sni == "" || !r.sniRe.MatchString(sni) {
+			return false
+		}
+	}
+	if r.cidr != nil {
+		if destIP == nil || !r.cidr.Contains(destIP) {
+			return false
+		}
+	}
+	if r.Port != 0 && r.Port != port {
+		return false
+	}
+	return true
+}
+
+// override resolves r into an Override, seeding the gap bounds from the
+// given default bounds so that a rule overriding only one of gap_min_ms /
+// gap_max_ms inherits the other instead of collapsing it to zero.
+func (r *Rule) override(defaultGapMin, defaultGapMax time.Duration) Override {
+	o := Override{MatchedRule: r.Name}
+	switch {
+	case r.SplitRandom > 0:
+		o.Splits = tls.Splits{Random: r.SplitRandom}
+		o.HasSplits = true
+	case len(r.SplitOffsets) > 0:
+		o.Splits = tls.Splits{Offsets: r.SplitOffsets}
+		o.HasSplits = true
+	case r.FirstFragment != nil && *r.FirstFragment == 0:
+		// Symmetric with the top-level --first 0: disables splitting for
+		// destinations matching this rule rather than cutting at offset 0,
+		// which SplitClientHello rejects as an empty leading fragment.
+		o.Splits = tls.Splits{}
+		o.HasSplits = true
+	case r.FirstFragment != nil:
+		o.Splits = tls.Splits{Offsets: []int{*r.FirstFragment}}
+		o.HasSplits = true
+	}
+
+	if r.GapMinMs != nil || r.GapMaxMs != nil {
+		o.HasGap = true
+		o.GapMin = defaultGapMin
+		o.GapMax = defaultGapMax
+		if r.GapMinMs != nil {
+			o.GapMin = time.Duration(*r.GapMinMs) * time.Millisecond
+		}
+		if r.GapMaxMs != nil {
+			o.GapMax = time.Duration(*r.GapMaxMs) * time.Millisecond
+		}
+	}
+	if r.GapDist != "" {
+		o.GapDist = tls.GapDistribution(r.GapDist)
+		o.HasGapDist = true
+	}
+	if r.ProxyProtocol != nil {
+		o.ProxyProtocol = *r.ProxyProtocol
+		o.HasProxyProtocol = true
+	}
+	return o
+}
+
+// Resolve returns the override for the first rule matching sni/destIP/port,
+// falling back to the document's default rule if none match. Fields a
+// matching rule leaves unset are taken from the default rule where it sets
+// them. defaultGapMin and defaultGapMax are the server's configured gap
+// bounds, used to fill in whichever bound is still unset after that.
+func (e *Engine) Resolve(sni string, destIP net.IP, port int, defaultGapMin, defaultGapMax time.Duration) Override {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	// The document's own default rule sits between a matching rule and the
+	// process-wide flags in the fallback chain, so a rule that only
+	// overrides one gap bound inherits the document default's bound (if
+	// set) rather than jumping straight to the CLI flags.
+	fallback := e.fallback.override(defaultGapMin, defaultGapMax)
+	gapMin, gapMax := defaultGapMin, defaultGapMax
+	if fallback.HasGap {
+		gapMin, gapMax = fallback.GapMin, fallback.GapMax
+	}
+
+	for _, r := range e.rules {
+		if r.matches(sni, destIP, port) {
+			o := r.override(gapMin, gapMax)
+			if !o.HasSplits && fallback.HasSplits {
+				o.Splits, o.HasSplits = fallback.Splits, true
+			}
+			if !o.HasGap && fallback.HasGap {
+				o.GapMin, o.GapMax, o.HasGap = fallback.GapMin, fallback.GapMax, true
+			}
+			if !o.HasGapDist && fallback.HasGapDist {
+				o.GapDist, o.HasGapDist = fallback.GapDist, true
+			}
+			if !o.HasProxyProtocol && fallback.HasProxyProtocol {
+				o.ProxyProtocol, o.HasProxyProtocol = fallback.ProxyProtocol, true
+			}
+			return o
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,31 @@
+package policy
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// WatchSIGHUP reloads the policy document from disk every time the process
+// receives SIGHUP, until ctx is cancelled. Reload failures are logged and
+// leave the previously loaded rules in effect.
+func (e *Engine) WatchSIGHUP(ctx context.Context, log *slog.Logger) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGHUP)
+	defer signal.Stop(sigCh)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sigCh:
+			if err := e.Reload(); err != nil {
+				log.Error("policy reload failed; keeping previous rules", "path", e.path, "error", err)
+				continue
+			}
+			log.Info("policy reloaded", "path", e.path)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net"
+)
+
+// negotiateFunc runs a front-end-specific handshake on a freshly accepted
+// connection and returns the conn to use for the rest of the pipeline (which
+// may differ from the input if the handshake buffered bytes past its own
+// framing, as HTTP CONNECT can) along with the destination address to dial.
+type negotiateFunc func(net.Conn) (net.Conn, string, error)
+
+// newFrontend builds the listener and, for front-ends that recover the
+// destination out-of-band instead of from the original TPROXY-rewritten
+// local address, the negotiation handshake to run on each accepted
+// connection.
+func (s *Server) newFrontend(ctx context.Context) (net.Listener, negotiateFunc, error) {
+	switch s.config.Mode {
+	case "tproxy", "":
+		listener, err := newTransparentListener(ctx, s.config, s.log)
+		return listener, nil, err
+
+	case "socks5":
+		listener, err := newPlainListener(ctx, s.config.ListenAddr)
+		if err != nil {
+			return nil, nil, err
+		}
+		return listener, func(conn net.Conn) (net.Conn, string, error) {
+			destAddr, err := acceptSOCKS5(conn)
+			return conn, destAddr, err
+		}, nil
+
+	case "http":
+		listener, err := newPlainListener(ctx, s.config.ListenAddr)
+		if err != nil {
+			return nil, nil, err
+		}
+		var auth *BasicAuth
+		if s.config.HTTPAuthFile != "" {
+			auth, err = LoadBasicAuth(s.config.HTTPAuthFile)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		return listener, func(conn net.Conn) (net.Conn, string, error) {
+			return acceptHTTPConnect(conn, auth)
+		}, nil
+
+	default:
+		return nil, nil, fmt.Errorf("unsupported mode %q", s.config.Mode)
+	}
+}
@@ -0,0 +1,56 @@
+package proxy
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// BasicAuth validates HTTP Basic credentials against an htpasswd-style file
+// (user:bcrypt-hash per line, as produced by `htpasswd -B`).
+type BasicAuth struct {
+	hashes map[string]string
+}
+
+// LoadBasicAuth reads an htpasswd-style file from path.
+func LoadBasicAuth(path string) (*BasicAuth, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open htpasswd file: %w", err)
+	}
+	defer f.Close()
+
+	hashes := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		user, hash, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("malformed htpasswd line %q", line)
+		}
+		hashes[user] = hash
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read htpasswd file: %w", err)
+	}
+
+	return &BasicAuth{hashes: hashes}, nil
+}
+
+// Validate reports whether user/pass matches an entry in the htpasswd file.
+func (a *BasicAuth) Validate(user, pass string) bool {
+	if a == nil {
+		return true
+	}
+	hash, ok := a.hashes[user]
+	if !ok {
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(pass)) == nil
+}
@@ -0,0 +1,72 @@
+package proxy
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// acceptHTTPConnect reads an HTTP/1.1 CONNECT request off client, optionally
+// enforcing Basic auth via auth, and returns the requested destination
+// address together with a net.Conn that replays any bytes the parser
+// buffered past the request line before the caller reads the ClientHello.
+// On success it writes the "200 Connection Established" response expected
+// by HTTP CONNECT clients before any tunnelled bytes flow.
+func acceptHTTPConnect(client net.Conn, auth *BasicAuth) (net.Conn, string, error) {
+	reader := bufio.NewReader(client)
+	req, err := http.ReadRequest(reader)
+	if err != nil {
+		return nil, "", fmt.Errorf("read CONNECT request: %w", err)
+	}
+	if req.Method != http.MethodConnect {
+		_, _ = client.Write([]byte("HTTP/1.1 405 Method Not Allowed\r\n\r\n"))
+		return nil, "", fmt.Errorf("unsupported method %q", req.Method)
+	}
+
+	if auth != nil {
+		user, pass, ok := parseBasicAuth(req.Header.Get("Proxy-Authorization"))
+		if !ok || !auth.Validate(user, pass) {
+			_, _ = client.Write([]byte("HTTP/1.1 407 Proxy Authentication Required\r\n" +
+				"Proxy-Authenticate: Basic realm=\"terasu-proxy\"\r\n\r\n"))
+			return nil, "", fmt.Errorf("proxy authentication failed")
+		}
+	}
+
+	destAddr := req.Host
+	if _, _, err := net.SplitHostPort(destAddr); err != nil {
+		destAddr = net.JoinHostPort(destAddr, "443")
+	}
+
+	if _, err := client.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n")); err != nil {
+		return nil, "", fmt.Errorf("write CONNECT response: %w", err)
+	}
+
+	return &bufferedConn{Conn: client, r: reader}, destAddr, nil
+}
+
+// bufferedConn replays bytes already buffered in r before falling back to
+// reading directly from the underlying net.Conn.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+func parseBasicAuth(header string) (user, pass string, ok bool) {
+	const prefix = "Basic "
+	if !strings.HasPrefix(header, prefix) {
+		return "", "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(header[len(prefix):])
+	if err != nil {
+		return "", "", false
+	}
+	user, pass, ok = strings.Cut(string(decoded), ":")
+	return user, pass, ok
+}
@@ -4,17 +4,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net"
 	"syscall"
 
 	"golang.org/x/sys/unix"
 
-	"github.com/sirupsen/logrus"
-
 	"github.com/Nativu5/terasu-proxy/internal/utils"
 )
 
-func newTransparentListener(ctx context.Context, config utils.Config, logger *logrus.Entry) (net.Listener, error) {
+func newTransparentListener(ctx context.Context, config utils.Config, logger *slog.Logger) (net.Listener, error) {
 	lc := net.ListenConfig{
 		Control: func(network, address string, c syscall.RawConn) error {
 			var setupErr error
@@ -28,10 +27,10 @@ func newTransparentListener(ctx context.Context, config utils.Config, logger *lo
 					return
 				}
 				if err := unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1); err != nil && !errors.Is(err, unix.ENOPROTOOPT) && !errors.Is(err, unix.EINVAL) {
-					logger.WithError(err).Debug("set SO_REUSEPORT failed")
+					logger.Debug("set SO_REUSEPORT failed", "error", err)
 				}
 				if err := unix.SetsockoptInt(int(fd), unix.SOL_IPV6, unix.IPV6_TRANSPARENT, 1); err != nil && !errors.Is(err, unix.ENOPROTOOPT) && !errors.Is(err, unix.EINVAL) {
-					logger.WithError(err).Debug("set IPV6_TRANSPARENT failed")
+					logger.Debug("set IPV6_TRANSPARENT failed", "error", err)
 				}
 			}); err != nil {
 				return err
@@ -46,3 +45,15 @@ func newTransparentListener(ctx context.Context, config utils.Config, logger *lo
 	}
 	return listener, nil
 }
+
+// newPlainListener opens an ordinary (non-transparent) TCP listener for the
+// socks5 and http front-ends, which recover the destination address from
+// the client's own handshake rather than from IP_TRANSPARENT/TPROXY.
+func newPlainListener(ctx context.Context, addr string) (net.Listener, error) {
+	var lc net.ListenConfig
+	listener, err := lc.Listen(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listen %s/%s: %w", "tcp", addr, err)
+	}
+	return listener, nil
+}
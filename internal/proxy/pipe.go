@@ -3,35 +3,88 @@ package proxy
 import (
 	"errors"
 	"io"
+	"log/slog"
 	"net"
+	"os"
 	"sync"
+	"time"
 
-	"github.com/sirupsen/logrus"
+	"github.com/Nativu5/terasu-proxy/internal/metrics"
 )
 
-func pipeBidirectional(log *logrus.Entry, client, upstream net.Conn) {
+// readOnlyReader hides any ReaderFrom/WriterTo optimizations a net.Conn may
+// implement, forcing io.CopyBuffer to use our pooled buffer instead of
+// silently falling back to the kernel splice path.
+type readOnlyReader struct{ io.Reader }
+
+// writeOnlyWriter is the write-side counterpart of readOnlyReader.
+type writeOnlyWriter struct{ io.Writer }
+
+func newRelayBufferPool(size int) *sync.Pool {
+	if size <= 0 {
+		size = 32 * 1024
+	}
+	return &sync.Pool{
+		New: func() any {
+			buf := make([]byte, size)
+			return &buf
+		},
+	}
+}
+
+func pipe(log *slog.Logger, client, upstream net.Conn, m *metrics.Metrics, pool *sync.Pool) {
 	var wg sync.WaitGroup
 	wg.Add(2)
 
 	go func() {
 		defer wg.Done()
-		if _, err := io.Copy(upstream, client); err != nil && !isClosedNetworkError(err) {
-			log.WithError(err).Debug("client -> upstream copy error")
+		n, err := relay(upstream, client, pool)
+		m.AddBytesRelayed("client_to_upstream", n)
+		if err != nil && !isClosedNetworkError(err) {
+			log.Debug("client -> upstream copy error", "error", err)
 		}
 		closeWrite(upstream)
+		setReadDeadlineNow(upstream)
 	}()
 
 	go func() {
 		defer wg.Done()
-		if _, err := io.Copy(client, upstream); err != nil && !isClosedNetworkError(err) {
-			log.WithError(err).Debug("upstream -> client copy error")
+		n, err := relay(client, upstream, pool)
+		m.AddBytesRelayed("upstream_to_client", n)
+		if err != nil && !isClosedNetworkError(err) {
+			log.Debug("upstream -> client copy error", "error", err)
 		}
 		closeWrite(client)
+		setReadDeadlineNow(client)
 	}()
 
 	wg.Wait()
 }
 
+// relay copies from src to dst. When both ends are *net.TCPConn it hands the
+// copy to (*net.TCPConn).ReadFrom, which on Linux moves bytes via splice(2)
+// without ever landing them in userspace. Otherwise it falls back to a
+// pooled-buffer io.CopyBuffer so no allocation happens per connection.
+func relay(dst, src net.Conn, pool *sync.Pool) (int64, error) {
+	if tcpDst, ok := dst.(*net.TCPConn); ok {
+		if tcpSrc, ok := src.(*net.TCPConn); ok {
+			return tcpDst.ReadFrom(tcpSrc)
+		}
+	}
+
+	buf := pool.Get().(*[]byte)
+	defer pool.Put(buf)
+	return io.CopyBuffer(writeOnlyWriter{dst}, readOnlyReader{src}, *buf)
+}
+
+// setReadDeadlineNow forces a blocked Read on conn to return promptly. It is
+// used on the conn read by the other direction's goroutine once this
+// direction has finished, so a dead peer does not leave both copies running
+// until their own independent timeouts.
+func setReadDeadlineNow(conn net.Conn) {
+	_ = conn.SetReadDeadline(time.Now())
+}
+
 func closeWrite(conn net.Conn) {
 	type closeWriter interface {
 		CloseWrite() error
@@ -53,5 +106,11 @@ func isClosedNetworkError(err error) bool {
 	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrClosedPipe) {
 		return true
 	}
+	// setReadDeadlineNow forces the peer's blocked Read to return once the
+	// other direction has finished; that is a deliberate unblock, not a
+	// stalled connection, so it should not be logged as a copy error.
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return true
+	}
 	return false
 }
@@ -0,0 +1,85 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+)
+
+// proxyProtocolV2Signature is the fixed 12-byte magic that opens every PROXY
+// protocol v2 header.
+var proxyProtocolV2Signature = []byte{
+	0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51, 0x55, 0x49, 0x54, 0x0A,
+}
+
+const (
+	proxyProtocolV2VerCmd     = 0x21 // version 2, command PROXY
+	proxyProtocolV2FamilyTCP4 = 0x11
+	proxyProtocolV2FamilyTCP6 = 0x21
+
+	// proxyProtocolV2FragmentedTLVType is a vendor TLV, in the range HAProxy
+	// reserves for application-specific data (PP2_TYPE_MIN_CUSTOM..MAX), that
+	// signals the following ClientHello has already been fragmented by this
+	// proxy so a downstream terasu-aware hop can skip re-fragmenting it.
+	proxyProtocolV2FragmentedTLVType = 0xE1
+)
+
+// buildProxyProtocolHeader builds a PROXY protocol header describing a TCP
+// connection from src to dst. version must be "v1" or "v2"; fragmented is
+// only encoded in v2, via a custom TLV.
+func buildProxyProtocolHeader(version string, src, dst *net.TCPAddr, fragmented bool) ([]byte, error) {
+	switch version {
+	case "v1":
+		return buildProxyProtocolV1(src, dst)
+	case "v2":
+		return buildProxyProtocolV2(src, dst, fragmented)
+	default:
+		return nil, fmt.Errorf("unsupported proxy protocol version %q", version)
+	}
+}
+
+func buildProxyProtocolV1(src, dst *net.TCPAddr) ([]byte, error) {
+	proto := "TCP4"
+	if src.IP.To4() == nil {
+		proto = "TCP6"
+	}
+	header := fmt.Sprintf("PROXY %s %s %s %d %d\r\n", proto, src.IP.String(), dst.IP.String(), src.Port, dst.Port)
+	if len(header) > 107 {
+		return nil, fmt.Errorf("proxy protocol v1 header exceeds 107 bytes")
+	}
+	return []byte(header), nil
+}
+
+func buildProxyProtocolV2(src, dst *net.TCPAddr, fragmented bool) ([]byte, error) {
+	var addrs []byte
+	family := byte(proxyProtocolV2FamilyTCP4)
+
+	srcIP4, dstIP4 := src.IP.To4(), dst.IP.To4()
+	if srcIP4 != nil && dstIP4 != nil {
+		addrs = make([]byte, 0, 12)
+		addrs = append(addrs, srcIP4...)
+		addrs = append(addrs, dstIP4...)
+	} else {
+		family = proxyProtocolV2FamilyTCP6
+		srcIP6, dstIP6 := src.IP.To16(), dst.IP.To16()
+		if srcIP6 == nil || dstIP6 == nil {
+			return nil, fmt.Errorf("invalid source/destination address for proxy protocol v2")
+		}
+		addrs = make([]byte, 0, 36)
+		addrs = append(addrs, srcIP6...)
+		addrs = append(addrs, dstIP6...)
+	}
+	addrs = append(addrs, byte(src.Port>>8), byte(src.Port), byte(dst.Port>>8), byte(dst.Port))
+
+	var tlvs []byte
+	if fragmented {
+		tlvs = append(tlvs, proxyProtocolV2FragmentedTLVType, 0x00, 0x01, 0x01)
+	}
+
+	length := len(addrs) + len(tlvs)
+	header := make([]byte, 0, len(proxyProtocolV2Signature)+4+length)
+	header = append(header, proxyProtocolV2Signature...)
+	header = append(header, proxyProtocolV2VerCmd, family, byte(length>>8), byte(length))
+	header = append(header, addrs...)
+	header = append(header, tlvs...)
+	return header, nil
+}
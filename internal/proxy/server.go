@@ -4,30 +4,37 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net"
+	"strconv"
 	"sync"
 	"syscall"
 	"time"
 
-	"github.com/sirupsen/logrus"
-
+	"github.com/Nativu5/terasu-proxy/internal/metrics"
+	"github.com/Nativu5/terasu-proxy/internal/policy"
 	"github.com/Nativu5/terasu-proxy/internal/tls"
 	"github.com/Nativu5/terasu-proxy/internal/utils"
 )
 
 // Server implements the transparent TLS proxy with ClientHello record splitting.
 type Server struct {
-	config utils.Config
-	log    *logrus.Entry
-	dialer *net.Dialer
-	gapMin time.Duration
-	gapMax time.Duration
+	config    utils.Config
+	log       *slog.Logger
+	dialer    *net.Dialer
+	gapMin    time.Duration
+	gapMax    time.Duration
+	gapDist   tls.GapDistribution
+	splits    tls.Splits
+	metrics   *metrics.Metrics
+	relayPool *sync.Pool
+	policy    *policy.Engine
 }
 
 // NewServer builds a Server using the provided configuration and logger.
-func NewServer(config utils.Config, logger *logrus.Entry) *Server {
+func NewServer(config utils.Config, logger *slog.Logger) *Server {
 	if logger == nil {
-		logger = logrus.NewEntry(logrus.StandardLogger())
+		logger = slog.Default()
 	}
 
 	dialer := &net.Dialer{
@@ -49,27 +56,66 @@ func NewServer(config utils.Config, logger *logrus.Entry) *Server {
 	}
 
 	return &Server{
-		config: config,
-		log:    logger.WithField("component", "server"),
-		dialer: dialer,
-		gapMin: config.GapMin,
-		gapMax: config.GapMax,
+		config:    config,
+		log:       logger.With("component", "server"),
+		dialer:    dialer,
+		gapMin:    config.GapMin,
+		gapMax:    config.GapMax,
+		gapDist:   config.GapDistribution,
+		splits:    splitsFromConfig(config),
+		metrics:   metrics.New(),
+		relayPool: newRelayBufferPool(config.RelayBufSize),
+	}
+}
+
+// splitsFromConfig resolves the configured split schedule, falling back to
+// the legacy single --first cut when --splits was not provided.
+func splitsFromConfig(config utils.Config) tls.Splits {
+	switch {
+	case config.SplitRandomCount > 0:
+		return tls.Splits{Random: config.SplitRandomCount}
+	case len(config.SplitOffsets) > 0:
+		return tls.Splits{Offsets: config.SplitOffsets}
+	case config.FirstFragment > 0:
+		return tls.Splits{Offsets: []int{config.FirstFragment}}
+	default:
+		return tls.Splits{}
 	}
 }
 
 // Run starts listening and processing connections until the context is cancelled or a fatal error occurs.
 func (s *Server) Run(ctx context.Context) error {
-	listener, err := newTransparentListener(ctx, s.config, s.log)
+	if s.config.PolicyFile != "" {
+		engine, err := policy.Load(s.config.PolicyFile)
+		if err != nil {
+			return err
+		}
+		s.policy = engine
+		go engine.WatchSIGHUP(ctx, s.log.With("component", "policy"))
+	}
+
+	listener, negotiate, err := s.newFrontend(ctx)
 	if err != nil {
 		return err
 	}
 	defer listener.Close()
 
-	s.log.Infof("transparent proxy listening at %s", s.config.ListenAddr)
+	s.log.Info("proxy listening", "mode", s.config.Mode, "addr", s.config.ListenAddr)
 
 	var wg sync.WaitGroup
 	defer wg.Wait()
 
+	if s.config.MetricsListen != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.log.Info("metrics listening", "addr", s.config.MetricsListen)
+			if err := s.metrics.Serve(ctx, s.config.MetricsListen); err != nil {
+				s.log.Error("metrics server failed", "error", err)
+			}
+		}()
+	}
+
 	go func() {
 		<-ctx.Done()
 		_ = listener.Close()
@@ -87,7 +133,7 @@ func (s *Server) Run(ctx context.Context) error {
 				return nil
 			}
 			if ne, ok := err.(net.Error); ok && ne.Timeout() {
-				s.log.WithError(err).Warn("accept timeout")
+				s.log.Warn("accept timeout", "error", err)
 				time.Sleep(50 * time.Millisecond)
 				continue
 			}
@@ -97,20 +143,129 @@ func (s *Server) Run(ctx context.Context) error {
 		wg.Add(1)
 		go func(conn net.Conn) {
 			defer wg.Done()
-			s.handleConn(ctx, conn)
+			s.acceptConn(ctx, conn, negotiate)
 		}(client)
 	}
 }
 
-func (s *Server) handleConn(ctx context.Context, client net.Conn) {
+// acceptConn runs the front-end's negotiation handshake, if any, to recover
+// the destination address before handing the connection to handleConn.
+func (s *Server) acceptConn(ctx context.Context, client net.Conn, negotiate negotiateFunc) {
+	if negotiate == nil {
+		s.handleConn(ctx, client, client.LocalAddr().String())
+		return
+	}
+
+	// Bound the handshake the same way tls.ReadInitialRecord bounds the
+	// TPROXY path's initial read, so a client that opens a connection and
+	// never completes the SOCKS5/CONNECT handshake can't park the goroutine
+	// and its fd forever.
+	if err := client.SetReadDeadline(time.Now().Add(s.config.ReadTimeout)); err != nil {
+		s.log.Debug("set negotiation read deadline failed", "peer", client.RemoteAddr().String(), "error", err)
+		client.Close()
+		return
+	}
+
+	conn, destAddr, err := negotiate(client)
+	if err != nil {
+		s.log.Debug("front-end negotiation failed", "peer", client.RemoteAddr().String(), "error", err)
+		client.Close()
+		return
+	}
+
+	if err := conn.SetReadDeadline(time.Time{}); err != nil {
+		s.log.Debug("clear negotiation read deadline failed", "peer", client.RemoteAddr().String(), "error", err)
+		conn.Close()
+		return
+	}
+
+	s.handleConn(ctx, conn, destAddr)
+}
+
+// connParams is the set of per-connection parameters resolved from the
+// server's defaults and, if configured, a matching policy rule.
+type connParams struct {
+	splits        tls.Splits
+	gapMin        time.Duration
+	gapMax        time.Duration
+	gapDist       tls.GapDistribution
+	proxyProtocol string
+}
+
+// resolveConnParams applies the policy engine's override, if configured and
+// matching, on top of the server's default split/gap/proxy-protocol parameters.
+func (s *Server) resolveConnParams(connLog *slog.Logger, record *tls.Record, origDst string) connParams {
+	params := connParams{
+		splits:        s.splits,
+		gapMin:        s.gapMin,
+		gapMax:        s.gapMax,
+		gapDist:       s.gapDist,
+		proxyProtocol: s.config.ProxyProtocol,
+	}
+	if s.policy == nil {
+		return params
+	}
+
+	sni, _ := tls.ExtractSNI(record.Payload)
+	var destIP net.IP
+	port := 0
+	if host, portStr, err := net.SplitHostPort(origDst); err == nil {
+		destIP = net.ParseIP(host)
+		port, _ = strconv.Atoi(portStr)
+	}
+
+	override := s.policy.Resolve(sni, destIP, port, s.gapMin, s.gapMax)
+	if override.HasSplits {
+		params.splits = override.Splits
+	}
+	if override.HasGap {
+		params.gapMin, params.gapMax = override.GapMin, override.GapMax
+	}
+	if override.HasGapDist {
+		params.gapDist = override.GapDist
+	}
+	if override.HasProxyProtocol {
+		params.proxyProtocol = override.ProxyProtocol
+	}
+	if override.MatchedRule != "" {
+		connLog.Debug("policy rule matched", "rule", override.MatchedRule, "sni", sni)
+	}
+	return params
+}
+
+// writeProxyProtocolHeader emits a PROXY protocol header to upstream
+// describing the original client, so that the true client IP survives a
+// deployment where this proxy sits transparently in front of another proxy.
+func (s *Server) writeProxyProtocolHeader(upstream, client net.Conn, version string, fragmented bool) error {
+	srcAddr, ok := client.RemoteAddr().(*net.TCPAddr)
+	if !ok {
+		return fmt.Errorf("client remote address is not TCP: %v", client.RemoteAddr())
+	}
+	// Use the address the dialer actually connected to rather than
+	// re-resolving origDst, which may be a hostname under --mode
+	// socks5/http and could resolve to a different address than the one
+	// upstream is dialed to.
+	dstAddr, ok := upstream.RemoteAddr().(*net.TCPAddr)
+	if !ok {
+		return fmt.Errorf("upstream remote address is not TCP: %v", upstream.RemoteAddr())
+	}
+
+	header, err := buildProxyProtocolHeader(version, srcAddr, dstAddr, fragmented)
+	if err != nil {
+		return err
+	}
+	if _, err := upstream.Write(header); err != nil {
+		return fmt.Errorf("write proxy protocol header: %w", err)
+	}
+	return nil
+}
+
+func (s *Server) handleConn(ctx context.Context, client net.Conn, origDst string) {
 	defer client.Close()
+	s.metrics.IncConnectionsAccepted()
 
-	origDst := client.LocalAddr().String()
 	peer := client.RemoteAddr().String()
-	connLog := s.log.WithFields(logrus.Fields{
-		"peer": peer,
-		"dst":  origDst,
-	})
+	connLog := s.log.With("peer", peer, "dst", origDst)
 	connLog.Info("accepted connection")
 
 	dialCtx, cancel := context.WithTimeout(ctx, s.config.DialTimeout)
@@ -118,7 +273,8 @@ func (s *Server) handleConn(ctx context.Context, client net.Conn) {
 
 	upstream, err := s.dialer.DialContext(dialCtx, "tcp", origDst)
 	if err != nil {
-		connLog.WithError(err).Warn("dial upstream failed")
+		s.metrics.IncUpstreamDialFailures()
+		connLog.Warn("dial upstream failed", "error", err)
 		return
 	}
 	defer upstream.Close()
@@ -138,45 +294,63 @@ func (s *Server) handleConn(ctx context.Context, client net.Conn) {
 	}()
 	defer close(stopCh)
 
-	record, rawBytes, err := tls.ReadInitialRecord(client, s.config.ReadTimeout, s.config.MaxRecordSize)
+	record, rawBytes, err := tls.ReadInitialRecord(client, s.config.ReadTimeout, s.config.MaxRecordSize, s.metrics)
 	if err != nil {
 		if len(rawBytes) > 0 {
 			if _, writeErr := upstream.Write(rawBytes); writeErr != nil {
-				connLog.WithError(writeErr).Warn("forward partial data to upstream failed")
+				connLog.Warn("forward partial data to upstream failed", "error", writeErr)
 				return
 			}
 		}
-		connLog.WithError(err).Debug("falling back to transparent piping after read failure")
-		pipe(connLog, client, upstream)
+		connLog.Debug("falling back to transparent piping after read failure", "error", err)
+		pipe(connLog, client, upstream, s.metrics, s.relayPool)
 		return
 	}
 
-	records, err := record.SplitClientHello(s.config.FirstFragment)
+	params := s.resolveConnParams(connLog, record, origDst)
+
+	records, err := record.SplitClientHello(params.splits)
 	if err != nil {
 		if errors.Is(err, tls.ErrNotHandshake) || errors.Is(err, tls.ErrNotClientHello) {
 			connLog.Debug("first record not ClientHello handshake; forwarding transparently")
 		} else {
-			connLog.WithError(err).Warn("unable to split ClientHello; forwarding transparently")
+			s.metrics.IncClientHelloParseFailures()
+			connLog.Warn("unable to split ClientHello; forwarding transparently", "error", err)
+		}
+		if params.proxyProtocol != "" {
+			if err := s.writeProxyProtocolHeader(upstream, client, params.proxyProtocol, false); err != nil {
+				connLog.Warn("writing PROXY protocol header failed", "error", err)
+				return
+			}
 		}
 		if len(rawBytes) > 0 {
 			if _, writeErr := upstream.Write(rawBytes); writeErr != nil {
-				connLog.WithError(writeErr).Warn("forward initial record upstream failed")
+				connLog.Warn("forward initial record upstream failed", "error", writeErr)
 				return
 			}
 		}
-		pipe(connLog, client, upstream)
+		pipe(connLog, client, upstream, s.metrics, s.relayPool)
 		return
 	}
 
-	if err := tls.WriteRecords(upstream, records, s.gapMin, s.gapMax); err != nil {
-		connLog.WithError(err).Warn("writing split records failed")
+	if params.proxyProtocol != "" {
+		if err := s.writeProxyProtocolHeader(upstream, client, params.proxyProtocol, len(records) > 1); err != nil {
+			connLog.Warn("writing PROXY protocol header failed", "error", err)
+			return
+		}
+	}
+
+	if err := tls.WriteRecords(upstream, records, params.gapMin, params.gapMax, params.gapDist, s.metrics); err != nil {
+		connLog.Warn("writing split records failed", "error", err)
 		return
 	}
 	if len(records) > 1 {
+		s.metrics.IncRecordsSplit()
 		connLog.Debug("successfully split ClientHello record")
 	} else {
+		s.metrics.IncRecordsForwarded()
 		connLog.Debug("forwarded ClientHello without splitting (first fragment disabled)")
 	}
 
-	pipe(connLog, client, upstream)
+	pipe(connLog, client, upstream, s.metrics, s.relayPool)
 }
@@ -0,0 +1,132 @@
+package proxy
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+)
+
+// SOCKS5 protocol constants from RFC 1928. Only the subset needed for a
+// CONNECT-only front-end is implemented.
+const (
+	socks5Version = 0x05
+
+	socks5AuthNone           = 0x00
+	socks5AuthNoneAcceptable = 0xFF
+
+	socks5CmdConnect = 0x01
+
+	socks5AddrIPv4   = 0x01
+	socks5AddrDomain = 0x03
+	socks5AddrIPv6   = 0x04
+
+	socks5ReplySucceeded     = 0x00
+	socks5ReplyCmdNotSupport = 0x07
+)
+
+// acceptSOCKS5 performs the RFC 1928 handshake on client, requiring no
+// authentication and accepting only the CONNECT command, then returns the
+// requested destination address. It replies with socks5ReplySucceeded on
+// success so the client starts streaming immediately, mirroring how a real
+// SOCKS5 CONNECT proxy would behave even though the actual dial happens
+// later in Server.handleConn.
+func acceptSOCKS5(client net.Conn) (string, error) {
+	methodHeader := make([]byte, 2)
+	if _, err := io.ReadFull(client, methodHeader); err != nil {
+		return "", fmt.Errorf("read socks5 greeting: %w", err)
+	}
+	if methodHeader[0] != socks5Version {
+		return "", fmt.Errorf("unsupported socks version %d", methodHeader[0])
+	}
+
+	methods := make([]byte, methodHeader[1])
+	if _, err := io.ReadFull(client, methods); err != nil {
+		return "", fmt.Errorf("read socks5 auth methods: %w", err)
+	}
+
+	supportsNone := false
+	for _, m := range methods {
+		if m == socks5AuthNone {
+			supportsNone = true
+			break
+		}
+	}
+	if !supportsNone {
+		_, _ = client.Write([]byte{socks5Version, socks5AuthNoneAcceptable})
+		return "", fmt.Errorf("client does not offer no-auth method")
+	}
+	if _, err := client.Write([]byte{socks5Version, socks5AuthNone}); err != nil {
+		return "", fmt.Errorf("write socks5 method selection: %w", err)
+	}
+
+	reqHeader := make([]byte, 4)
+	if _, err := io.ReadFull(client, reqHeader); err != nil {
+		return "", fmt.Errorf("read socks5 request: %w", err)
+	}
+	if reqHeader[0] != socks5Version {
+		return "", fmt.Errorf("unsupported socks version %d", reqHeader[0])
+	}
+	if reqHeader[1] != socks5CmdConnect {
+		_, _ = client.Write(socks5Reply(socks5ReplyCmdNotSupport))
+		return "", fmt.Errorf("unsupported socks5 command %d", reqHeader[1])
+	}
+
+	host, err := readSOCKS5Addr(client, reqHeader[3])
+	if err != nil {
+		return "", err
+	}
+
+	portBytes := make([]byte, 2)
+	if _, err := io.ReadFull(client, portBytes); err != nil {
+		return "", fmt.Errorf("read socks5 port: %w", err)
+	}
+	port := binary.BigEndian.Uint16(portBytes)
+
+	if _, err := client.Write(socks5Reply(socks5ReplySucceeded)); err != nil {
+		return "", fmt.Errorf("write socks5 reply: %w", err)
+	}
+
+	return net.JoinHostPort(host, strconv.Itoa(int(port))), nil
+}
+
+func readSOCKS5Addr(client net.Conn, addrType byte) (string, error) {
+	switch addrType {
+	case socks5AddrIPv4:
+		addr := make([]byte, 4)
+		if _, err := io.ReadFull(client, addr); err != nil {
+			return "", fmt.Errorf("read socks5 ipv4 address: %w", err)
+		}
+		return net.IP(addr).String(), nil
+	case socks5AddrIPv6:
+		addr := make([]byte, 16)
+		if _, err := io.ReadFull(client, addr); err != nil {
+			return "", fmt.Errorf("read socks5 ipv6 address: %w", err)
+		}
+		return net.IP(addr).String(), nil
+	case socks5AddrDomain:
+		lenByte := make([]byte, 1)
+		if _, err := io.ReadFull(client, lenByte); err != nil {
+			return "", fmt.Errorf("read socks5 domain length: %w", err)
+		}
+		domain := make([]byte, lenByte[0])
+		if _, err := io.ReadFull(client, domain); err != nil {
+			return "", fmt.Errorf("read socks5 domain: %w", err)
+		}
+		return string(domain), nil
+	default:
+		return "", fmt.Errorf("unsupported socks5 address type %d", addrType)
+	}
+}
+
+// socks5Reply builds a minimal CONNECT reply carrying the given status and a
+// zero bind address, which is all real clients need once they stop waiting
+// for a handshake reply.
+func socks5Reply(status byte) []byte {
+	return []byte{
+		socks5Version, status, 0x00, socks5AddrIPv4,
+		0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00,
+	}
+}
@@ -7,7 +7,10 @@ import (
 	"io"
 	"math/rand"
 	"net"
+	"sort"
 	"time"
+
+	"github.com/Nativu5/terasu-proxy/internal/metrics"
 )
 
 const (
@@ -24,8 +27,67 @@ var (
 	ErrRecordTooLarge = errors.New("record exceeds configured limit")
 	// ErrHandshakeTooShort signals the handshake payload is smaller than the minimum header.
 	ErrHandshakeTooShort = errors.New("handshake record too short")
+	// ErrInvalidSplitOffsets is returned when a split schedule is not strictly
+	// increasing or falls outside the payload's splittable range.
+	ErrInvalidSplitOffsets = errors.New("split offsets must be strictly increasing and within the handshake payload")
+)
+
+// GapDistribution selects how selectGapDuration samples a gap within [min, max].
+type GapDistribution string
+
+const (
+	// GapUniform draws uniformly from [min, max]. This is the default.
+	GapUniform GapDistribution = "uniform"
+	// GapExponential draws from an exponential distribution with mean
+	// (min+max)/2, clamped to [min, max], approximating the heavy-tailed
+	// jitter seen on real network paths.
+	GapExponential GapDistribution = "exponential"
 )
 
+// Splits configures how a ClientHello payload is fragmented into records.
+// Exactly one of Offsets or Random should be set; the zero value disables
+// splitting.
+type Splits struct {
+	// Offsets are explicit, strictly increasing cut points into the
+	// handshake payload (e.g. []int{1, 5, 20, 64}).
+	Offsets []int
+	// Random, if > 0, requests that many random cut points be chosen per
+	// connection instead of using Offsets.
+	Random int
+}
+
+// resolve returns the concrete offsets to cut payloadLen bytes at. Random
+// offsets are sorted; explicit Offsets are returned as given and are not
+// checked here.
+func (s Splits) resolve(payloadLen int) ([]int, error) {
+	if s.Random > 0 {
+		return randomOffsets(s.Random, payloadLen)
+	}
+	return s.Offsets, nil
+}
+
+func randomOffsets(n, payloadLen int) ([]int, error) {
+	available := payloadLen - 1
+	if available <= 0 {
+		return nil, ErrInvalidSplitOffsets
+	}
+	if n > available {
+		n = available
+	}
+
+	chosen := make(map[int]struct{}, n)
+	for len(chosen) < n {
+		chosen[1+rand.Intn(available)] = struct{}{}
+	}
+
+	offsets := make([]int, 0, len(chosen))
+	for o := range chosen {
+		offsets = append(offsets, o)
+	}
+	sort.Ints(offsets)
+	return offsets, nil
+}
+
 // Record represents a single TLS record payload.
 type Record struct {
 	ContentType uint8
@@ -35,7 +97,8 @@ type Record struct {
 
 // ReadInitialRecord reads the first TLS record from conn with an optional timeout and size cap.
 // It returns the parsed Record, the raw bytes read, and any error encountered.
-func ReadInitialRecord(conn net.Conn, timeout time.Duration, maxSize int) (*Record, []byte, error) {
+// m may be nil if metrics collection is disabled.
+func ReadInitialRecord(conn net.Conn, timeout time.Duration, maxSize int, m *metrics.Metrics) (*Record, []byte, error) {
 	if timeout > 0 {
 		_ = conn.SetReadDeadline(time.Now().Add(timeout))
 		defer conn.SetReadDeadline(time.Time{})
@@ -55,12 +118,13 @@ func ReadInitialRecord(conn net.Conn, timeout time.Duration, maxSize int) (*Reco
 
 	length := int(binary.BigEndian.Uint16(header[3:5]))
 	if length > maxSize {
+		m.IncClientHelloParseFailures()
 		return nil, total, ErrRecordTooLarge
 	}
 
 	payload := make([]byte, length)
-	m, err := io.ReadFull(conn, payload)
-	total = append(total, payload[:m]...)
+	nRead, err := io.ReadFull(conn, payload)
+	total = append(total, payload[:nRead]...)
 	if err != nil {
 		return nil, total, err
 	}
@@ -73,16 +137,19 @@ func ReadInitialRecord(conn net.Conn, timeout time.Duration, maxSize int) (*Reco
 	return record, append([]byte(nil), total...), nil
 }
 
-// WriteRecords emits one or more TLS records to conn with an optional random gap between the first two.
-func WriteRecords(conn net.Conn, records []Record, gapMin, gapMax time.Duration) error {
+// WriteRecords emits one or more TLS records to conn, applying a gap sampled
+// from dist between every consecutive pair of records.
+// m may be nil if metrics collection is disabled.
+func WriteRecords(conn net.Conn, records []Record, gapMin, gapMax time.Duration, dist GapDistribution, m *metrics.Metrics) error {
 	for idx, rec := range records {
 		if err := rec.Write(conn); err != nil {
 			return err
 		}
 
-		if idx == 0 && len(records) > 1 {
-			gap := selectGapDuration(gapMin, gapMax)
+		if idx < len(records)-1 {
+			gap := selectGapDuration(gapMin, gapMax, dist)
 			if gap > 0 {
+				m.ObserveGap(gap)
 				time.Sleep(gap)
 			}
 		}
@@ -90,8 +157,10 @@ func WriteRecords(conn net.Conn, records []Record, gapMin, gapMax time.Duration)
 	return nil
 }
 
-// SplitClientHello divides a ClientHello handshake across one or two new TLS records.
-func (rec *Record) SplitClientHello(first int) ([]Record, error) {
+// SplitClientHello divides a ClientHello handshake into records cut at
+// splits' resolved offsets. A zero-value Splits (or one that resolves to no
+// offsets) returns the handshake as a single, unsplit record.
+func (rec *Record) SplitClientHello(splits Splits) ([]Record, error) {
 	if rec.ContentType != recordTypeHandshake {
 		return nil, ErrNotHandshake
 	}
@@ -107,21 +176,48 @@ func (rec *Record) SplitClientHello(first int) ([]Record, error) {
 		return nil, fmt.Errorf("handshake length mismatch: header=%d payload=%d", bodyLength, len(rec.Payload)-4)
 	}
 
-	if first <= 0 || first >= len(rec.Payload) {
+	offsets, err := splits.resolve(len(rec.Payload))
+	if err != nil {
+		return nil, err
+	}
+	if len(offsets) == 0 {
 		return []Record{{
 			ContentType: rec.ContentType,
 			Version:     rec.Version,
 			Payload:     append([]byte(nil), rec.Payload...),
 		}}, nil
 	}
+	if err := validateSplitOffsets(offsets, len(rec.Payload)); err != nil {
+		return nil, err
+	}
 
-	firstPayload := append([]byte(nil), rec.Payload[:first]...)
-	secondPayload := append([]byte(nil), rec.Payload[first:]...)
+	records := make([]Record, 0, len(offsets)+1)
+	prev := 0
+	for _, off := range offsets {
+		records = append(records, Record{
+			ContentType: rec.ContentType,
+			Version:     rec.Version,
+			Payload:     append([]byte(nil), rec.Payload[prev:off]...),
+		})
+		prev = off
+	}
+	records = append(records, Record{
+		ContentType: rec.ContentType,
+		Version:     rec.Version,
+		Payload:     append([]byte(nil), rec.Payload[prev:]...),
+	})
+	return records, nil
+}
 
-	return []Record{
-		{ContentType: rec.ContentType, Version: rec.Version, Payload: firstPayload},
-		{ContentType: rec.ContentType, Version: rec.Version, Payload: secondPayload},
-	}, nil
+func validateSplitOffsets(offsets []int, payloadLen int) error {
+	prev := 0
+	for _, off := range offsets {
+		if off <= prev || off >= payloadLen {
+			return ErrInvalidSplitOffsets
+		}
+		prev = off
+	}
+	return nil
 }
 
 // Write emits a single TLS record to conn using writev for zero-copy operation.
@@ -146,7 +242,7 @@ func (rec *Record) Write(conn net.Conn) error {
 	return nil
 }
 
-func selectGapDuration(min, max time.Duration) time.Duration {
+func selectGapDuration(min, max time.Duration, dist GapDistribution) time.Duration {
 	if max <= 0 {
 		return 0
 	}
@@ -156,6 +252,11 @@ func selectGapDuration(min, max time.Duration) time.Duration {
 	if max < min {
 		max = min
 	}
+
+	if dist == GapExponential {
+		return selectExponentialGap(min, max)
+	}
+
 	span := max - min
 	if span <= 0 {
 		return max
@@ -163,3 +264,21 @@ func selectGapDuration(min, max time.Duration) time.Duration {
 	n := rand.Int63n(int64(span) + 1)
 	return min + time.Duration(n)
 }
+
+// selectExponentialGap draws from an exponential distribution with mean
+// (min+max)/2 and clamps the result to [min, max] so a long tail cannot
+// stall the connection indefinitely.
+func selectExponentialGap(min, max time.Duration) time.Duration {
+	mean := float64(min+max) / 2
+	if mean <= 0 {
+		return min
+	}
+	sample := time.Duration(rand.ExpFloat64() * mean)
+	if sample < min {
+		return min
+	}
+	if sample > max {
+		return max
+	}
+	return sample
+}
@@ -0,0 +1,80 @@
+package tls
+
+import "testing"
+
+// clientHelloPayload builds a minimal ClientHello handshake (type + 3-byte
+// length + all-zero body: client_version + random + empty session_id +
+// empty cipher_suites + empty compression_methods + no extensions), with
+// the body zero-padded to bodyLen bytes so SplitClientHello can be cut at
+// offsets past the fixed fields.
+func clientHelloPayload(bodyLen int) []byte {
+	body := make([]byte, 2+32+1+2+1+2) // version, random, session_id_len, cipher_suites_len, compression_methods_len, extensions_len
+	if bodyLen > len(body) {
+		body = append(body, make([]byte, bodyLen-len(body))...)
+	}
+	payload := make([]byte, 4+len(body))
+	payload[0] = handshakeTypeClientHello
+	n := len(body)
+	payload[1] = byte(n >> 16)
+	payload[2] = byte(n >> 8)
+	payload[3] = byte(n)
+	copy(payload[4:], body)
+	return payload
+}
+
+func TestSplitClientHello_DefaultFirst(t *testing.T) {
+	rec := &Record{ContentType: recordTypeHandshake, Payload: clientHelloPayload(64)}
+
+	records, err := rec.SplitClientHello(Splits{Offsets: []int{3}})
+	if err != nil {
+		t.Fatalf("SplitClientHello with the default --first 3 offset: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+	if len(records[0].Payload) != 3 {
+		t.Fatalf("expected first fragment of 3 bytes, got %d", len(records[0].Payload))
+	}
+}
+
+func TestSplitClientHello_DocumentedMultiSplit(t *testing.T) {
+	rec := &Record{ContentType: recordTypeHandshake, Payload: clientHelloPayload(64)}
+
+	records, err := rec.SplitClientHello(Splits{Offsets: []int{1, 5, 20, 64}})
+	if err != nil {
+		t.Fatalf("SplitClientHello with --splits 1,5,20,64: %v", err)
+	}
+	if len(records) != 5 {
+		t.Fatalf("expected 5 records, got %d", len(records))
+	}
+}
+
+func TestValidateSplitOffsets(t *testing.T) {
+	const payloadLen = 40
+	cases := []struct {
+		name    string
+		offsets []int
+		wantErr bool
+	}{
+		{"default first 3", []int{3}, false},
+		{"cuts within header range 1-4", []int{1, 2, 3, 4}, false},
+		{"strictly increasing", []int{1, 5, 20}, false},
+		{"not increasing", []int{5, 5}, true},
+		{"descending", []int{10, 5}, true},
+		{"zero offset", []int{0}, true},
+		{"at payload length", []int{payloadLen}, true},
+		{"past payload length", []int{payloadLen + 1}, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateSplitOffsets(tc.offsets, payloadLen)
+			if tc.wantErr && err == nil {
+				t.Fatalf("expected an error, got nil")
+			}
+			if !tc.wantErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package tls
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// ErrNoServerName is returned when a ClientHello has no server_name extension.
+var ErrNoServerName = errors.New("no server_name extension present")
+
+const extensionTypeServerName = 0x0000
+
+// ExtractSNI parses a ClientHello handshake body (as found in Record.Payload)
+// and returns the hostname carried in its server_name extension, so the
+// policy engine can match rules before any splitting decision is made.
+func ExtractSNI(payload []byte) (string, error) {
+	if len(payload) < 4 || payload[0] != handshakeTypeClientHello {
+		return "", ErrNotClientHello
+	}
+
+	// Skip the 4-byte handshake header, 2-byte client_version and 32-byte random.
+	pos := 4 + 2 + 32
+	if pos+1 > len(payload) {
+		return "", ErrHandshakeTooShort
+	}
+
+	sessionIDLen := int(payload[pos])
+	pos++
+	pos += sessionIDLen
+	if pos+2 > len(payload) {
+		return "", ErrHandshakeTooShort
+	}
+
+	cipherSuitesLen := int(binary.BigEndian.Uint16(payload[pos : pos+2]))
+	pos += 2 + cipherSuitesLen
+	if pos+1 > len(payload) {
+		return "", ErrHandshakeTooShort
+	}
+
+	compressionMethodsLen := int(payload[pos])
+	pos++
+	pos += compressionMethodsLen
+	if pos+2 > len(payload) {
+		return "", ErrNoServerName
+	}
+
+	extensionsLen := int(binary.BigEndian.Uint16(payload[pos : pos+2]))
+	pos += 2
+	end := pos + extensionsLen
+	if end > len(payload) {
+		return "", ErrHandshakeTooShort
+	}
+
+	for pos+4 <= end {
+		extType := binary.BigEndian.Uint16(payload[pos : pos+2])
+		extLen := int(binary.BigEndian.Uint16(payload[pos+2 : pos+4]))
+		pos += 4
+		if pos+extLen > end {
+			return "", ErrHandshakeTooShort
+		}
+
+		if extType == extensionTypeServerName {
+			return parseServerNameExtension(payload[pos : pos+extLen])
+		}
+		pos += extLen
+	}
+
+	return "", ErrNoServerName
+}
+
+func parseServerNameExtension(data []byte) (string, error) {
+	if len(data) < 2 {
+		return "", ErrNoServerName
+	}
+	listLen := int(binary.BigEndian.Uint16(data[0:2]))
+	pos := 2
+	end := pos + listLen
+	if end > len(data) {
+		end = len(data)
+	}
+
+	for pos+3 <= end {
+		nameType := data[pos]
+		nameLen := int(binary.BigEndian.Uint16(data[pos+1 : pos+3]))
+		pos += 3
+		if pos+nameLen > end {
+			break
+		}
+		if nameType == 0x00 { // host_name
+			return string(data[pos : pos+nameLen]), nil
+		}
+		pos += nameLen
+	}
+
+	return "", ErrNoServerName
+}
@@ -0,0 +1,59 @@
+package tls
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// clientHelloWithSNI builds a minimal ClientHello handshake body carrying a
+// single server_name extension for hostname, for exercising ExtractSNI.
+func clientHelloWithSNI(hostname string) []byte {
+	name := []byte(hostname)
+
+	serverNameEntry := make([]byte, 0, 3+len(name))
+	serverNameEntry = append(serverNameEntry, 0x00) // host_name
+	serverNameEntry = binary.BigEndian.AppendUint16(serverNameEntry, uint16(len(name)))
+	serverNameEntry = append(serverNameEntry, name...)
+
+	serverNameList := make([]byte, 0, 2+len(serverNameEntry))
+	serverNameList = binary.BigEndian.AppendUint16(serverNameList, uint16(len(serverNameEntry)))
+	serverNameList = append(serverNameList, serverNameEntry...)
+
+	extension := make([]byte, 0, 4+len(serverNameList))
+	extension = binary.BigEndian.AppendUint16(extension, extensionTypeServerName)
+	extension = binary.BigEndian.AppendUint16(extension, uint16(len(serverNameList)))
+	extension = append(extension, serverNameList...)
+
+	body := make([]byte, 0, 2+32+1+2+1+2+len(extension))
+	body = append(body, make([]byte, 2+32)...) // client_version + random
+	body = append(body, 0x00)                   // session_id_len
+	body = append(body, 0x00, 0x00)             // cipher_suites_len
+	body = append(body, 0x00)                   // compression_methods_len
+	body = binary.BigEndian.AppendUint16(body, uint16(len(extension)))
+	body = append(body, extension...)
+
+	payload := make([]byte, 0, 4+len(body))
+	payload = append(payload, handshakeTypeClientHello, byte(len(body)>>16), byte(len(body)>>8), byte(len(body)))
+	payload = append(payload, body...)
+	return payload
+}
+
+func TestExtractSNI(t *testing.T) {
+	payload := clientHelloWithSNI("example.com")
+
+	got, err := ExtractSNI(payload)
+	if err != nil {
+		t.Fatalf("ExtractSNI: %v", err)
+	}
+	if got != "example.com" {
+		t.Fatalf("got SNI %q, want %q", got, "example.com")
+	}
+}
+
+func TestExtractSNI_NoServerName(t *testing.T) {
+	payload := clientHelloPayload(40)
+
+	if _, err := ExtractSNI(payload); err != ErrNoServerName {
+		t.Fatalf("got error %v, want %v", err, ErrNoServerName)
+	}
+}
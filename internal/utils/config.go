@@ -7,19 +7,30 @@ import (
 	"time"
 
 	"github.com/spf13/cobra"
+
+	"github.com/Nativu5/terasu-proxy/internal/tls"
 )
 
 // Config captures runtime parameters for the transparent TLS proxy.
 type Config struct {
-	ListenAddr    string
-	FirstFragment int
-	GapMin        time.Duration
-	GapMax        time.Duration
-	ReadTimeout   time.Duration
-	DialTimeout   time.Duration
-	MaxRecordSize int
-	Mark          int
-	LogLevel      string
+	ListenAddr       string
+	FirstFragment    int
+	SplitOffsets     []int
+	SplitRandomCount int
+	GapMin           time.Duration
+	GapMax           time.Duration
+	GapDistribution  tls.GapDistribution
+	ReadTimeout      time.Duration
+	DialTimeout      time.Duration
+	MaxRecordSize    int
+	Mark             int
+	LogLevel         string
+	MetricsListen    string
+	RelayBufSize     int
+	Mode             string
+	HTTPAuthFile     string
+	PolicyFile       string
+	ProxyProtocol    string
 }
 
 // NewRootCommand constructs the CLI root command backed by cobra and wires flag
@@ -28,6 +39,8 @@ func NewRootCommand(run func(Config) error) *cobra.Command {
 	var (
 		config        Config
 		gapSpec       string
+		gapDistSpec   string
+		splitsSpec    string
 		readTimeoutMs int
 		dialTimeoutMs int
 	)
@@ -47,6 +60,19 @@ func NewRootCommand(run func(Config) error) *cobra.Command {
 			config.ReadTimeout = time.Duration(readTimeoutMs) * time.Millisecond
 			config.DialTimeout = time.Duration(dialTimeoutMs) * time.Millisecond
 
+			dist, err := parseGapDistribution(gapDistSpec)
+			if err != nil {
+				return err
+			}
+			config.GapDistribution = dist
+
+			offsets, randomCount, err := parseSplitSpec(splitsSpec)
+			if err != nil {
+				return err
+			}
+			config.SplitOffsets = offsets
+			config.SplitRandomCount = randomCount
+
 			if err := validateConfig(&config); err != nil {
 				return err
 			}
@@ -60,12 +86,20 @@ func NewRootCommand(run func(Config) error) *cobra.Command {
 
 	flags := cmd.Flags()
 	flags.StringVar(&config.ListenAddr, "listen", ":15001", "transparent listen address (TPROXY target)")
-	flags.IntVar(&config.FirstFragment, "first", 3, "number of bytes to place in the first TLS record fragment (>=0). 0 disables splitting")
+	flags.IntVar(&config.FirstFragment, "first", 3, "number of bytes to place in the first TLS record fragment (>=0). 0 disables splitting. Ignored if --splits is set")
+	flags.StringVar(&splitsSpec, "splits", "", "comma-separated ascending byte offsets to cut the ClientHello at (e.g. 1,5,20,64), or random:N for N random cuts; overrides --first")
 	flags.IntVar(&config.MaxRecordSize, "max", 64*1024, "maximum TLS record payload to buffer for the first ClientHello record")
 	flags.IntVar(&config.Mark, "mark", 0x66, "SO_MARK value applied to upstream connections")
 	flags.StringVar(&config.LogLevel, "log-level", "info", "log level (debug, info, warn, error)")
+	flags.StringVar(&config.MetricsListen, "metrics-listen", "", "address to expose Prometheus /metrics on (empty disables metrics)")
+	flags.IntVar(&config.RelayBufSize, "relay-buf", 32*1024, "pooled buffer size (bytes) used to relay each direction of a connection")
+	flags.StringVar(&config.Mode, "mode", "tproxy", "front-end listener: tproxy, socks5, or http")
+	flags.StringVar(&config.HTTPAuthFile, "http-auth", "", "htpasswd-style file of bcrypt credentials required for --mode http (empty disables auth)")
+	flags.StringVar(&config.PolicyFile, "policy", "", "YAML/JSON policy file overriding split parameters per destination (empty disables the policy engine)")
+	flags.StringVar(&config.ProxyProtocol, "proxy-protocol", "", "emit a PROXY protocol header to upstream before any TLS bytes: v1, v2, or empty to disable (overridable per-destination via --policy)")
 
 	flags.StringVar(&gapSpec, "gap", "0,0", "gap range in milliseconds formatted as min,max (e.g. 1,10)")
+	flags.StringVar(&gapDistSpec, "gap-dist", "uniform", "distribution used to sample the gap within its range (uniform, exponential)")
 	flags.IntVar(&readTimeoutMs, "rt", 250, "read timeout (ms) while waiting for the initial TLS record")
 	flags.IntVar(&dialTimeoutMs, "dial", 5000, "upstream dial timeout in milliseconds")
 
@@ -85,6 +119,9 @@ func validateConfig(config *Config) error {
 	if config.MaxRecordSize <= 0 {
 		return errors.New("max record size must be positive")
 	}
+	if config.RelayBufSize <= 0 {
+		return errors.New("relay buffer size must be positive")
+	}
 	if config.Mark < 0 {
 		return errors.New("SO_MARK must be >= 0")
 	}
@@ -94,6 +131,34 @@ func validateConfig(config *Config) error {
 	if config.GapMin > config.GapMax {
 		return errors.New("gap-min must not exceed gap-max")
 	}
+	switch config.GapDistribution {
+	case tls.GapUniform, tls.GapExponential:
+	default:
+		return errors.New("unsupported gap distribution")
+	}
+	switch config.Mode {
+	case "tproxy", "socks5", "http":
+	default:
+		return errors.New("mode must be one of tproxy, socks5, http")
+	}
+	if config.HTTPAuthFile != "" && config.Mode != "http" {
+		return errors.New("http-auth is only valid with --mode http")
+	}
+	switch config.ProxyProtocol {
+	case "", "v1", "v2":
+	default:
+		return errors.New("proxy-protocol must be one of v1, v2")
+	}
+	if config.SplitRandomCount < 0 {
+		return errors.New("splits random count must be >= 0")
+	}
+	prev := 0
+	for _, off := range config.SplitOffsets {
+		if off <= prev {
+			return errors.New("split offsets must be strictly increasing and positive")
+		}
+		prev = off
+	}
 	if config.ReadTimeout <= 0 {
 		return errors.New("read timeout must be positive")
 	}
@@ -147,3 +212,47 @@ func parseGapRange(spec string) (int, int, error) {
 	}
 	return minVal, maxVal, nil
 }
+
+func parseGapDistribution(spec string) (tls.GapDistribution, error) {
+	switch strings.ToLower(strings.TrimSpace(spec)) {
+	case "", "uniform":
+		return tls.GapUniform, nil
+	case "exponential", "poisson":
+		return tls.GapExponential, nil
+	default:
+		return "", errors.New("gap distribution must be uniform or exponential")
+	}
+}
+
+// parseSplitSpec parses --splits into either an explicit ascending offset
+// list or a request for N random cuts, formatted as "random:N".
+func parseSplitSpec(spec string) ([]int, int, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, 0, nil
+	}
+
+	if rest, ok := strings.CutPrefix(spec, "random:"); ok {
+		n, err := strconv.Atoi(strings.TrimSpace(rest))
+		if err != nil || n <= 0 {
+			return nil, 0, errors.New("splits random count must be formatted as random:N with N > 0")
+		}
+		return nil, n, nil
+	}
+
+	parts := strings.Split(spec, ",")
+	offsets := make([]int, 0, len(parts))
+	prev := 0
+	for _, p := range parts {
+		v, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil {
+			return nil, 0, errors.New("split offsets must be comma-separated integers")
+		}
+		if v <= prev {
+			return nil, 0, errors.New("split offsets must be strictly increasing and positive")
+		}
+		offsets = append(offsets, v)
+		prev = v
+	}
+	return offsets, 0, nil
+}
@@ -10,6 +10,7 @@ import (
 	nested "github.com/antonfisher/nested-logrus-formatter"
 	"github.com/sirupsen/logrus"
 
+	"github.com/Nativu5/terasu-proxy/internal/logging"
 	"github.com/Nativu5/terasu-proxy/internal/proxy"
 	"github.com/Nativu5/terasu-proxy/internal/utils"
 )
@@ -32,7 +33,7 @@ func main() {
 		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 		defer stop()
 
-		server := proxy.NewServer(config, logrus.NewEntry(logger))
+		server := proxy.NewServer(config, logging.NewLogrusLogger(logger))
 		return server.Run(ctx)
 	})
 